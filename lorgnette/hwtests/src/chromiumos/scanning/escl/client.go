@@ -0,0 +1,113 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package escl is a minimal client for the eSCL scan protocol: just
+// enough to issue a ScanJobs request and pull the resulting document,
+// which is all the hwtests package needs to drive a real scan.
+package escl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ScanSettings describes a single eSCL scan request.
+type ScanSettings struct {
+	// Source is the eSCL InputSource to scan from: "Platen", "Feeder" or
+	// "Camera". There's no ADF-specific InputSource for duplex; that's
+	// requested via Duplex instead.
+	Source string
+	// Duplex requests both sides of the page from the ADF. It's only
+	// meaningful when Source is "Feeder".
+	Duplex bool
+	// XResolution and YResolution are the requested resolution, in
+	// pixels per inch.
+	XResolution int
+	YResolution int
+	// ColorMode is the eSCL ColorMode to request, e.g. "RGB24".
+	ColorMode string
+	// Format is the MIME type to request in DocumentFormat, e.g.
+	// "image/jpeg".
+	Format string
+}
+
+// Client issues eSCL scan requests against a single scanner and returns
+// the scanned document's raw bytes.
+type Client interface {
+	// Scan issues a ScanJobs request with settings, waits for the job to
+	// produce a document, and returns that document's bytes.
+	Scan(settings ScanSettings) ([]byte, error)
+}
+
+// HTTPClient is a Client that talks to a real eSCL scanner over HTTP, as
+// described in the Mopria eSCL specification: POST the scan settings to
+// {baseURL}/ScanJobs, follow the Location header it returns, and GET
+// {job}/NextDocument for the scanned bytes.
+type HTTPClient struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewHTTPClient returns an HTTPClient that issues requests against
+// baseURL, e.g. "http://192.168.1.5/eSCL".
+func NewHTTPClient(baseURL string) *HTTPClient {
+	return &HTTPClient{BaseURL: baseURL, HTTP: http.DefaultClient}
+}
+
+// Scan implements Client.
+func (c *HTTPClient) Scan(settings ScanSettings) ([]byte, error) {
+	resp, err := c.HTTP.Post(c.BaseURL+"/ScanJobs", "text/xml", bytes.NewReader(scanSettingsXML(settings)))
+	if err != nil {
+		return nil, fmt.Errorf("posting ScanJobs: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("ScanJobs returned status %d", resp.StatusCode)
+	}
+
+	jobURL := resp.Header.Get("Location")
+	if jobURL == "" {
+		return nil, fmt.Errorf("ScanJobs response missing Location header")
+	}
+
+	docResp, err := c.HTTP.Get(jobURL + "/NextDocument")
+	if err != nil {
+		return nil, fmt.Errorf("getting NextDocument: %w", err)
+	}
+	defer docResp.Body.Close()
+	if docResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("NextDocument returned status %d", docResp.StatusCode)
+	}
+
+	doc, err := io.ReadAll(docResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading NextDocument body: %w", err)
+	}
+	return doc, nil
+}
+
+// scanSettingsXML renders settings as an eSCL ScanSettings document.
+func scanSettingsXML(settings ScanSettings) []byte {
+	var duplex string
+	if settings.Duplex {
+		duplex = "  <scan:Duplex>true</scan:Duplex>\n"
+	}
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<scan:ScanSettings xmlns:scan="http://schemas.hp.com/imaging/escl/2011/05/03" xmlns:pwg="http://www.pwg.org/schemas/2010/12/sm">
+  <pwg:Version>2.0</pwg:Version>
+  <pwg:ScanRegions>
+    <pwg:ScanRegion>
+      <pwg:ContentRegionUnits>escl:ThreeHundredthsOfInches</pwg:ContentRegionUnits>
+    </pwg:ScanRegion>
+  </pwg:ScanRegions>
+  <pwg:InputSource>%s</pwg:InputSource>
+%s  <scan:ColorMode>%s</scan:ColorMode>
+  <scan:XResolution>%d</scan:XResolution>
+  <scan:YResolution>%d</scan:YResolution>
+  <pwg:DocumentFormat>%s</pwg:DocumentFormat>
+</scan:ScanSettings>
+`, settings.Source, duplex, settings.ColorMode, settings.XResolution, settings.YResolution, settings.Format))
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package escl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScanSettingsXML(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings ScanSettings
+		want     []string
+		dontWant []string
+	}{
+		{
+			name:     "platen",
+			settings: ScanSettings{Source: "Platen", ColorMode: "RGB24", XResolution: 300, YResolution: 300, Format: "image/jpeg"},
+			want:     []string{"<pwg:InputSource>Platen</pwg:InputSource>"},
+			dontWant: []string{"<scan:Duplex>"},
+		},
+		{
+			name:     "feeder simplex",
+			settings: ScanSettings{Source: "Feeder", ColorMode: "RGB24", XResolution: 300, YResolution: 300, Format: "image/jpeg"},
+			want:     []string{"<pwg:InputSource>Feeder</pwg:InputSource>"},
+			dontWant: []string{"<scan:Duplex>"},
+		},
+		{
+			name:     "feeder duplex",
+			settings: ScanSettings{Source: "Feeder", Duplex: true, ColorMode: "RGB24", XResolution: 300, YResolution: 300, Format: "image/jpeg"},
+			want:     []string{"<pwg:InputSource>Feeder</pwg:InputSource>", "<scan:Duplex>true</scan:Duplex>"},
+		},
+	}
+
+	for _, tc := range tests {
+		xml := string(scanSettingsXML(tc.settings))
+		for _, want := range tc.want {
+			if !strings.Contains(xml, want) {
+				t.Errorf("%s: XML missing %q:\n%s", tc.name, want, xml)
+			}
+		}
+		for _, dontWant := range tc.dontWant {
+			if strings.Contains(xml, dontWant) {
+				t.Errorf("%s: XML unexpectedly contains %q:\n%s", tc.name, dontWant, xml)
+			}
+		}
+	}
+}
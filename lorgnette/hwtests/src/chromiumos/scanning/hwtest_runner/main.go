@@ -0,0 +1,126 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Command hwtest_runner runs the chromiumos/scanning/hwtests checks
+// against a scanner's capabilities and prints the resulting report,
+// grouped by tier, to stdout.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"chromiumos/scanning/escl"
+	"chromiumos/scanning/hwtests"
+	"chromiumos/scanning/utils"
+)
+
+func main() {
+	model := flag.String("model", "", "scanner model, used to match suppressions")
+	firmware := flag.String("firmware", "", "scanner firmware version, used to match suppressions")
+	suppressionsPath := flag.String("suppressions", "", "path to a suppression list YAML file")
+	profilePath := flag.String("profile", "", "path to a resolution profile YAML file; overrides --tier")
+	tier := flag.String("tier", "basic", "built-in certification tier to test against (basic, standard, premium), used when --profile isn't set")
+	platenCapsPath := flag.String("platen_caps", "", "path to the platen source's capabilities, JSON-encoded")
+	adfSimplexCapsPath := flag.String("adf_simplex_caps", "", "path to the ADF simplex source's capabilities, JSON-encoded")
+	adfDuplexCapsPath := flag.String("adf_duplex_caps", "", "path to the ADF duplex source's capabilities, JSON-encoded")
+	scannerURL := flag.String("scanner_url", "", "base URL of the scanner's eSCL service, e.g. http://192.168.1.5/eSCL; if set, also runs ScanAndDecodeTest against the live scanner")
+	flag.Parse()
+
+	report, err := run(*model, *firmware, *suppressionsPath, *profilePath, *tier, *platenCapsPath, *adfSimplexCapsPath, *adfDuplexCapsPath, *scannerURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Print(report)
+
+	// A Required-tier failure means the scanner doesn't meet its
+	// certification tier; signal that via exit status so a CI job
+	// running hwtest_runner fails instead of silently passing.
+	if len(report.ByLevel[hwtests.Required]) > 0 {
+		os.Exit(1)
+	}
+}
+
+func run(model, firmware, suppressionsPath, profilePath, tier, platenCapsPath, adfSimplexCapsPath, adfDuplexCapsPath, scannerURL string) (*hwtests.Report, error) {
+	profile, err := loadProfile(profilePath, tier)
+	if err != nil {
+		return nil, fmt.Errorf("loading resolution profile: %w", err)
+	}
+
+	platenCaps, err := loadCapabilities(platenCapsPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading platen capabilities: %w", err)
+	}
+	adfSimplexCaps, err := loadCapabilities(adfSimplexCapsPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading ADF simplex capabilities: %w", err)
+	}
+	adfDuplexCaps, err := loadCapabilities(adfDuplexCapsPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading ADF duplex capabilities: %w", err)
+	}
+	sourceCaps := map[string]utils.SourceCapabilities{
+		"platen":      platenCaps,
+		"adf_simplex": adfSimplexCaps,
+		"adf_duplex":  adfDuplexCaps,
+	}
+
+	var suppressions []hwtests.Suppression
+	if suppressionsPath != "" {
+		suppressions, err = hwtests.LoadSuppressions(suppressionsPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading suppressions: %w", err)
+		}
+	}
+
+	var client escl.Client
+	if scannerURL != "" {
+		client = escl.NewHTTPClient(scannerURL)
+	}
+
+	report, err := hwtests.RunTests(hwtests.AllTests(profile, sourceCaps, client), model, firmware, suppressions, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("running hwtests: %w", err)
+	}
+	return report, nil
+}
+
+// loadProfile returns the resolution profile at profilePath, or, if
+// profilePath is empty, the built-in profile for tier.
+func loadProfile(profilePath, tier string) (hwtests.ResolutionProfile, error) {
+	if profilePath != "" {
+		return hwtests.LoadResolutionProfile(profilePath)
+	}
+	profile, ok := hwtests.DefaultProfiles[tier]
+	if !ok {
+		return nil, fmt.Errorf("unknown certification tier %q", tier)
+	}
+	return profile, nil
+}
+
+// loadCapabilities parses the JSON-encoded utils.SourceCapabilities at
+// path. An empty path yields the zero value, meaning the source isn't
+// present on the scanner.
+func loadCapabilities(path string) (utils.SourceCapabilities, error) {
+	if path == "" {
+		return utils.SourceCapabilities{}, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return utils.SourceCapabilities{}, err
+	}
+
+	var caps utils.SourceCapabilities
+	if err := json.Unmarshal(b, &caps); err != nil {
+		return utils.SourceCapabilities{}, err
+	}
+	return caps, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package hwtests contains the eSCL hardware certification checks run
+// against a scanner's advertised capabilities and, where supported, its
+// actual scan behavior.
+package hwtests
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"chromiumos/scanning/escl"
+	"chromiumos/scanning/utils"
+)
+
+// Level controls how strictly a Test's failures are enforced: whether a
+// failure keeps its reported FailureType, or is downgraded to a less
+// severe one before it reaches the report.
+type Level int
+
+const (
+	// Required checks report their failures at face value: a
+	// CriticalFailure stays a CriticalFailure.
+	Required Level = iota
+	// Preferred checks have their failures downgraded by one step: a
+	// CriticalFailure is reported as NeedsAudit.
+	Preferred
+	// Optional checks have all failures downgraded to Informational.
+	Optional
+)
+
+// String returns the tier name used to group a hwtests Report.
+func (l Level) String() string {
+	switch l {
+	case Required:
+		return "Required"
+	case Preferred:
+		return "Preferred"
+	case Optional:
+		return "Optional"
+	default:
+		return "Unknown"
+	}
+}
+
+// Test is a single hwtest check together with the tier it's enforced
+// at and the name suppressions refer to it by.
+type Test struct {
+	// Name uniquely identifies the check across runs; it's what
+	// Suppression.Check matches against.
+	Name string
+	// Level controls how the FailureTypes Run returns are adjusted
+	// before they reach the report.
+	Level Level
+	// Run executes the check against whatever capabilities or scan
+	// behavior it closed over and returns its raw failures.
+	Run func() ([]utils.Failure, error)
+}
+
+// scanSources are the eSCL source names AllTests registers a
+// ScanAndDecodeTest for, in this fixed order so a Report's check names
+// come out the same way across runs.
+var scanSources = []string{"platen", "adf_simplex", "adf_duplex"}
+
+// AllTests builds the full set of hwtests to run against a scanner,
+// wired up with profile (the required resolution matrix for the
+// certification tier being tested) and sourceCaps (that scanner's
+// capabilities gathered from its eSCL ScannerCapabilities response,
+// keyed by eSCL source name), leveled at the tier each check is enforced
+// at for certification. client is used to drive a real eSCL scan for
+// ScanAndDecodeTest; if client is nil, that check is omitted, since
+// without a scanner to talk to there's nothing for it to run against.
+func AllTests(profile ResolutionProfile, sourceCaps map[string]utils.SourceCapabilities, client escl.Client) []Test {
+	tests := []Test{
+		{
+			Name:  "HasSupportedResolutionTest",
+			Level: Required,
+			Run:   HasSupportedResolutionTest(profile, sourceCaps),
+		},
+	}
+
+	if client != nil {
+		for _, source := range scanSources {
+			caps := sourceCaps[source]
+			if caps.IsZero() {
+				continue
+			}
+			tests = append(tests, Test{
+				Name:  "ScanAndDecodeTest/" + source,
+				Level: Required,
+				Run:   ScanAndDecodeTest(client, source, caps),
+			})
+		}
+	}
+
+	return tests
+}
+
+// adjust downgrades t according to level: Preferred demotes a
+// CriticalFailure to NeedsAudit, and Optional demotes everything to
+// Informational. Required leaves t unchanged.
+func (l Level) adjust(t utils.FailureType) utils.FailureType {
+	switch l {
+	case Preferred:
+		if t == utils.CriticalFailure {
+			return utils.NeedsAudit
+		}
+	case Optional:
+		return utils.Informational
+	}
+	return t
+}
+
+// ReportedFailure is a single failure annotated with the check it came
+// from and, if a suppression applied, the suppression that explains why
+// it was downgraded to Informational.
+type ReportedFailure struct {
+	Check       string
+	Failure     utils.Failure
+	Suppression *Suppression
+}
+
+// Report is the outcome of running a set of Tests against one scanner,
+// with failures already leveled and suppressed, grouped by tier so a
+// regression can't quietly hide among routine Optional noise.
+type Report struct {
+	ByLevel map[Level][]ReportedFailure
+}
+
+// RunTests runs every test in tests, in order, against a scanner
+// identified by model and firmware. A failure whose test is covered by
+// an active entry in suppressions is reported as Informational with the
+// matching Suppression attached instead of at its test's normal tier.
+// now is used to decide whether a Temporary suppression has expired, and
+// is threaded through rather than read from time.Now so that a runner
+// can make the decision deterministically, e.g. in tests.
+func RunTests(tests []Test, model, firmware string, suppressions []Suppression, now time.Time) (*Report, error) {
+	report := &Report{ByLevel: map[Level][]ReportedFailure{}}
+	for _, test := range tests {
+		failures, err := test.Run()
+		if err != nil {
+			return nil, fmt.Errorf("running %s: %w", test.Name, err)
+		}
+
+		for _, failure := range failures {
+			if suppression := findSuppression(suppressions, test.Name, model, firmware, now); suppression != nil {
+				report.ByLevel[Optional] = append(report.ByLevel[Optional], ReportedFailure{
+					Check:       test.Name,
+					Failure:     utils.Failure{Type: utils.Informational, Message: failure.Message},
+					Suppression: suppression,
+				})
+				continue
+			}
+
+			failure.Type = test.Level.adjust(failure.Type)
+			report.ByLevel[test.Level] = append(report.ByLevel[test.Level], ReportedFailure{
+				Check:   test.Name,
+				Failure: failure,
+			})
+		}
+	}
+	return report, nil
+}
+
+func findSuppression(suppressions []Suppression, check, model, firmware string, now time.Time) *Suppression {
+	for i, suppression := range suppressions {
+		if suppression.matches(check, model, firmware) && suppression.active(now) {
+			return &suppressions[i]
+		}
+	}
+	return nil
+}
+
+// String renders the report grouped by tier, Required first, printing
+// each suppressed failure's justification and bug alongside it so a
+// regression hiding behind a stale suppression shows up in the output
+// rather than disappearing silently.
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, level := range []Level{Required, Preferred, Optional} {
+		failures := r.ByLevel[level]
+		if len(failures) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s:\n", level)
+		for _, f := range failures {
+			fmt.Fprintf(&b, "  [%s] %s: %s\n", f.Failure.Type, f.Check, f.Failure.Message)
+			if f.Suppression != nil {
+				fmt.Fprintf(&b, "    suppressed (%s): %s (%s)\n", f.Suppression.Kind, f.Suppression.Justification, f.Suppression.Bug)
+			}
+		}
+	}
+	return b.String()
+}
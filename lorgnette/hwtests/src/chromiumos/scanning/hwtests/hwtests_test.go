@@ -0,0 +1,133 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package hwtests
+
+import (
+	"testing"
+	"time"
+
+	"chromiumos/scanning/utils"
+)
+
+func TestLevelAdjust(t *testing.T) {
+	tests := []struct {
+		level Level
+		in    utils.FailureType
+		want  utils.FailureType
+	}{
+		{Required, utils.CriticalFailure, utils.CriticalFailure},
+		{Required, utils.NeedsAudit, utils.NeedsAudit},
+		{Preferred, utils.CriticalFailure, utils.NeedsAudit},
+		{Preferred, utils.NeedsAudit, utils.NeedsAudit},
+		{Optional, utils.CriticalFailure, utils.Informational},
+		{Optional, utils.NeedsAudit, utils.Informational},
+	}
+
+	for _, tc := range tests {
+		if got := tc.level.adjust(tc.in); got != tc.want {
+			t.Errorf("%s.adjust(%s) = %s, want %s", tc.level, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestAllTests(t *testing.T) {
+	profile := ResolutionProfile{}
+	sourceCaps := map[string]utils.SourceCapabilities{
+		"platen":      {SettingProfile: utils.SettingProfile{DocumentFormats: []string{"image/jpeg"}}},
+		"adf_simplex": {},
+	}
+
+	withoutClient := AllTests(profile, sourceCaps, nil)
+	for _, test := range withoutClient {
+		if test.Name != "HasSupportedResolutionTest" {
+			t.Errorf("AllTests with nil client registered %q, want only HasSupportedResolutionTest", test.Name)
+		}
+	}
+
+	withClient := AllTests(profile, sourceCaps, &fakeClient{})
+	var gotScanTest bool
+	for _, test := range withClient {
+		if test.Name == "ScanAndDecodeTest/platen" {
+			gotScanTest = true
+		}
+		if test.Name == "ScanAndDecodeTest/adf_simplex" {
+			t.Error("AllTests registered ScanAndDecodeTest/adf_simplex, but that source has no capabilities")
+		}
+	}
+	if !gotScanTest {
+		t.Error("AllTests with a non-nil client didn't register ScanAndDecodeTest/platen")
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	now := time.Date(2026, time.July, 26, 0, 0, 0, 0, time.UTC)
+	failingTest := func() ([]utils.Failure, error) {
+		return []utils.Failure{{Type: utils.CriticalFailure, Message: "broken"}}, nil
+	}
+
+	tests := []struct {
+		name         string
+		level        Level
+		suppressions []Suppression
+		wantLevel    Level
+		wantType     utils.FailureType
+		wantSuppress bool
+	}{
+		{
+			name:      "required, no suppression",
+			level:     Required,
+			wantLevel: Required,
+			wantType:  utils.CriticalFailure,
+		},
+		{
+			name:      "preferred, no suppression",
+			level:     Preferred,
+			wantLevel: Preferred,
+			wantType:  utils.NeedsAudit,
+		},
+		{
+			name:  "required, suppressed",
+			level: Required,
+			suppressions: []Suppression{{
+				Check: "failingTest", Model: "Generic Scanner 1000", Kind: Permanent,
+				Justification: "known issue", Bug: "b/123456",
+			}},
+			wantLevel:    Optional,
+			wantType:     utils.Informational,
+			wantSuppress: true,
+		},
+		{
+			name:  "required, expired suppression",
+			level: Required,
+			suppressions: []Suppression{{
+				Check: "failingTest", Model: "Generic Scanner 1000", Kind: Temporary,
+				Justification: "fix in progress", Bug: "b/123456", Expires: "2026-01-01",
+			}},
+			wantLevel: Required,
+			wantType:  utils.CriticalFailure,
+		},
+	}
+
+	for _, tc := range tests {
+		report, err := RunTests([]Test{{Name: "failingTest", Level: tc.level, Run: failingTest}},
+			"Generic Scanner 1000", "1.0.0", tc.suppressions, now)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+
+		got := report.ByLevel[tc.wantLevel]
+		if len(got) != 1 {
+			t.Errorf("%s: ByLevel[%s] has %d failures, want 1", tc.name, tc.wantLevel, len(got))
+			continue
+		}
+		if got[0].Failure.Type != tc.wantType {
+			t.Errorf("%s: failure type = %s, want %s", tc.name, got[0].Failure.Type, tc.wantType)
+		}
+		if (got[0].Suppression != nil) != tc.wantSuppress {
+			t.Errorf("%s: Suppression set = %v, want %v", tc.name, got[0].Suppression != nil, tc.wantSuppress)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package hwtests
+
+import (
+	"embed"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+
+	"chromiumos/scanning/utils"
+)
+
+//go:embed profiles/*.yaml
+var defaultProfileFS embed.FS
+
+// defaultProfileTiers are the ChromeOS scanner certification tiers
+// shipped as built-in resolution profiles, from least to most
+// demanding.
+var defaultProfileTiers = []string{"basic", "standard", "premium"}
+
+// DefaultProfiles are the built-in resolution profiles for each
+// ChromeOS scanner certification tier, keyed by tier name, so the same
+// hwtest_runner binary can certify against different hardware targets
+// by passing --tier instead of a custom --profile.
+var DefaultProfiles = func() map[string]ResolutionProfile {
+	profiles := make(map[string]ResolutionProfile, len(defaultProfileTiers))
+	for _, tier := range defaultProfileTiers {
+		b, err := defaultProfileFS.ReadFile("profiles/" + tier + ".yaml")
+		if err != nil {
+			// The embedded profiles are part of the binary; a missing
+			// or malformed one is a build-time bug, not a runtime one.
+			panic(fmt.Sprintf("hwtests: embedded profile %q: %v", tier, err))
+		}
+		profile, err := parseResolutionProfile(b)
+		if err != nil {
+			panic(fmt.Sprintf("hwtests: embedded profile %q: %v", tier, err))
+		}
+		profiles[tier] = profile
+	}
+	return profiles
+}()
+
+// ResolutionMatchMethod controls how strictly a ResolutionProfileEntry's
+// requested resolution must be reachable by a source.
+type ResolutionMatchMethod string
+
+const (
+	// Exact requires the requested resolution to appear exactly in the
+	// source's DiscreteResolutions, or to land exactly on an integer
+	// Step of its ResolutionRange.
+	Exact ResolutionMatchMethod = "exact"
+	// NearestStep permits the source's closest reachable resolution to
+	// differ from the requested one by up to nearestStepTolerance.
+	NearestStep ResolutionMatchMethod = "nearest_step"
+)
+
+// nearestStepTolerance is how many DPI off a NearestStep entry's closest
+// reachable resolution is allowed to be.
+const nearestStepTolerance = 5
+
+// ResolutionProfileEntry is a single required row in a resolution
+// matrix: the scanner must be able to scan from Source at X/Y
+// resolution, in ColorMode, producing Format, reachable per Method.
+type ResolutionProfileEntry struct {
+	Source    string                `yaml:"source"`
+	X         int                   `yaml:"x"`
+	Y         int                   `yaml:"y"`
+	ColorMode string                `yaml:"color_mode"`
+	Format    string                `yaml:"format"`
+	Method    ResolutionMatchMethod `yaml:"method"`
+}
+
+// ResolutionProfile is the matrix of capabilities a scanner must support
+// to certify at some tier.
+type ResolutionProfile []ResolutionProfileEntry
+
+// LoadResolutionProfile parses a resolution profile from the YAML file
+// at path.
+func LoadResolutionProfile(path string) (ResolutionProfile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading resolution profile: %w", err)
+	}
+	return parseResolutionProfile(b)
+}
+
+func parseResolutionProfile(b []byte) (ResolutionProfile, error) {
+	var profile ResolutionProfile
+	if err := yaml.Unmarshal(b, &profile); err != nil {
+		return nil, fmt.Errorf("parsing resolution profile: %w", err)
+	}
+	for i, entry := range profile {
+		if err := entry.validate(); err != nil {
+			return nil, fmt.Errorf("resolution profile entry %d: %w", i, err)
+		}
+	}
+	return profile, nil
+}
+
+// validSources are the eSCL source names HasSupportedResolutionTest's
+// sourceCaps map is keyed by; a profile entry naming anything else can
+// never be satisfied.
+var validSources = map[string]bool{"platen": true, "adf_simplex": true, "adf_duplex": true}
+
+func (e ResolutionProfileEntry) validate() error {
+	if e.Source == "" {
+		return fmt.Errorf("source is required")
+	}
+	if !validSources[e.Source] {
+		return fmt.Errorf("source must be one of platen, adf_simplex, adf_duplex, got %q", e.Source)
+	}
+	if e.X <= 0 || e.Y <= 0 {
+		return fmt.Errorf("x and y must be positive")
+	}
+	if e.ColorMode == "" {
+		return fmt.Errorf("color_mode is required")
+	}
+	if e.Format == "" {
+		return fmt.Errorf("format is required")
+	}
+	switch e.Method {
+	case Exact, NearestStep:
+	default:
+		return fmt.Errorf("method must be %q or %q, got %q", Exact, NearestStep, e.Method)
+	}
+	return nil
+}
+
+// satisfies reports whether caps can scan from e.Source at e's
+// resolution, color mode and format, reachable per e.Method.
+func (e ResolutionProfileEntry) satisfies(caps utils.SourceCapabilities) bool {
+	if caps.IsZero() {
+		return false
+	}
+	if !containsString(caps.SettingProfile.ColorModes, e.ColorMode) {
+		return false
+	}
+	if !containsString(caps.SettingProfile.DocumentFormats, e.Format) {
+		return false
+	}
+	if !withinOpticalLimit(e.X, caps.MaxOpticalXResolution) || !withinOpticalLimit(e.Y, caps.MaxOpticalYResolution) {
+		return false
+	}
+
+	resolutions := caps.SettingProfile.SupportedResolutions
+	for _, d := range resolutions.DiscreteResolutions {
+		if d.XResolution == e.X && d.YResolution == e.Y {
+			return true
+		}
+	}
+
+	switch e.Method {
+	case Exact:
+		return rangeContainsStep(resolutions.XResolutionRange, e.X) && rangeContainsStep(resolutions.YResolutionRange, e.Y)
+	case NearestStep:
+		return rangeReachableWithinTolerance(resolutions.XResolutionRange, e.X, nearestStepTolerance) &&
+			rangeReachableWithinTolerance(resolutions.YResolutionRange, e.Y, nearestStepTolerance)
+	default:
+		return false
+	}
+}
+
+// withinOpticalLimit reports whether point is reachable without digital
+// upsampling given an optical limit, i.e. point <= limit. A limit of 0
+// means the scanner's capabilities didn't advertise one, which is
+// treated as "no limit" rather than "nothing is reachable", since most
+// real ScannerCapabilities responses omit the optical resolution fields
+// entirely.
+func withinOpticalLimit(point, limit int) bool {
+	return limit == 0 || point <= limit
+}
+
+// rangeContainsStep reports whether point is exactly reachable within r:
+// point is in [r.Min, r.Max] and point-r.Min is an integer multiple of
+// r.Step.
+func rangeContainsStep(r utils.ResolutionRange, point int) bool {
+	if point < r.Min || point > r.Max {
+		return false
+	}
+	if r.Step <= 0 {
+		return point == r.Min || point == r.Max
+	}
+	return (point-r.Min)%r.Step == 0
+}
+
+// rangeReachableWithinTolerance reports whether some value achievable in
+// r is within tolerance of point.
+func rangeReachableWithinTolerance(r utils.ResolutionRange, point, tolerance int) bool {
+	for _, v := range achievableInRange(r) {
+		diff := v - point
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= tolerance {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package hwtests
+
+import (
+	"testing"
+
+	"chromiumos/scanning/utils"
+)
+
+func TestDefaultProfilesLoad(t *testing.T) {
+	for _, tier := range []string{"basic", "standard", "premium"} {
+		profile, ok := DefaultProfiles[tier]
+		if !ok {
+			t.Errorf("DefaultProfiles missing tier %q", tier)
+			continue
+		}
+		if len(profile) == 0 {
+			t.Errorf("DefaultProfiles[%q] is empty", tier)
+		}
+	}
+
+	// Premium should be a strict superset of requirements: it has at
+	// least as many rows as basic.
+	if len(DefaultProfiles["premium"]) < len(DefaultProfiles["basic"]) {
+		t.Error("premium profile should have at least as many rows as basic")
+	}
+}
+
+func TestResolutionProfileEntryValidate(t *testing.T) {
+	valid := ResolutionProfileEntry{Source: "platen", X: 300, Y: 300, ColorMode: "RGB24", Format: "image/jpeg", Method: Exact}
+	if err := valid.validate(); err != nil {
+		t.Errorf("valid entry failed validation: %v", err)
+	}
+
+	tests := []ResolutionProfileEntry{
+		{X: 300, Y: 300, ColorMode: "RGB24", Format: "image/jpeg", Method: Exact},                    // missing source
+		{Source: "platen", Y: 300, ColorMode: "RGB24", Format: "image/jpeg", Method: Exact},          // missing x
+		{Source: "platen", X: 300, Y: 300, Format: "image/jpeg", Method: Exact},                      // missing color_mode
+		{Source: "platen", X: 300, Y: 300, ColorMode: "RGB24", Method: Exact},                        // missing format
+		{Source: "platen", X: 300, Y: 300, ColorMode: "RGB24", Format: "image/jpeg", Method: "soon"}, // bad method
+	}
+	for i, entry := range tests {
+		if err := entry.validate(); err == nil {
+			t.Errorf("case %d: expected validation error, got nil", i)
+		}
+	}
+}
+
+func TestRangeContainsStep(t *testing.T) {
+	r := utils.ResolutionRange{Min: 100, Max: 600, Step: 50}
+	if !rangeContainsStep(r, 300) {
+		t.Error("300 should be reachable via 50-step range from 100")
+	}
+	if rangeContainsStep(r, 325) {
+		t.Error("325 should not be reachable via 50-step range from 100")
+	}
+	if rangeContainsStep(r, 700) {
+		t.Error("700 is outside the range")
+	}
+}
+
+func TestRangeReachableWithinTolerance(t *testing.T) {
+	r := utils.ResolutionRange{Min: 100, Max: 600, Step: 49}
+	if !rangeReachableWithinTolerance(r, 300, 5) {
+		t.Error("296 (closest step to 300) should be within tolerance 5")
+	}
+	if rangeReachableWithinTolerance(r, 300, 1) {
+		t.Error("296 should not be within tolerance 1 of 300")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package hwtests
+
+import (
+	"fmt"
+
+	"chromiumos/scanning/utils"
+)
+
+// achievableInRange returns every resolution reachable by stepping from
+// r.Min up to r.Max. A zero-value range (no range-based resolutions
+// advertised) yields no values.
+func achievableInRange(r utils.ResolutionRange) []int {
+	if r.Min == 0 && r.Max == 0 && r.Step == 0 {
+		return nil
+	}
+	if r.Step <= 0 {
+		return []int{r.Min, r.Max}
+	}
+
+	var out []int
+	for v := r.Min; v <= r.Max; v += r.Step {
+		out = append(out, v)
+	}
+	return out
+}
+
+// HasSupportedResolutionTest returns a hwtest that checks, for every row
+// in profile, whether sourceCaps actually satisfies it: does the named
+// source support scanning at the row's resolution, color mode and
+// format, reachable per the row's ResolutionMatchMethod. sourceCaps maps
+// eSCL source names ("platen", "adf_simplex", "adf_duplex") to that
+// source's capabilities; a row naming a source missing from sourceCaps,
+// or whose capabilities are the zero value, is always unmet. This
+// reports one failure per unmet row, rather than a single pass/fail for
+// "does some common resolution happen to work".
+func HasSupportedResolutionTest(profile ResolutionProfile, sourceCaps map[string]utils.SourceCapabilities) func() ([]utils.Failure, error) {
+	return func() ([]utils.Failure, error) {
+		var failures []utils.Failure
+		for _, entry := range profile {
+			if entry.satisfies(sourceCaps[entry.Source]) {
+				continue
+			}
+			failures = append(failures, utils.Failure{
+				Type: utils.CriticalFailure,
+				Message: fmt.Sprintf("%s: no %dx%d %s %s resolution satisfying method %q",
+					entry.Source, entry.X, entry.Y, entry.ColorMode, entry.Format, entry.Method),
+			})
+		}
+		return failures, nil
+	}
+}
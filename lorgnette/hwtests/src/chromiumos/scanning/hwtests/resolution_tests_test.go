@@ -13,157 +13,211 @@ import (
 // TestHasSupportedResolutionTest tests that HasSupportedResolutionTest
 // functions correctly.
 func TestHasSupportedResolutionTest(t *testing.T) {
+	baseEntry := ResolutionProfileEntry{
+		Source:    "platen",
+		X:         300,
+		Y:         300,
+		ColorMode: "RGB24",
+		Format:    "image/jpeg",
+		Method:    Exact,
+	}
+
 	tests := []struct {
-		platenCaps     utils.SourceCapabilities
-		adfSimplexCaps utils.SourceCapabilities
-		adfDuplexCaps  utils.SourceCapabilities
-		failures       []utils.FailureType
+		profile     ResolutionProfile
+		platenCaps  utils.SourceCapabilities
+		adfSimplex  utils.SourceCapabilities
+		numFailures int
 	}{
 		{
-			// Should pass: both resolutions ranges include 75.
+			// Should pass: 300 is an allowed discrete resolution within
+			// the source's optical limits.
+			profile: ResolutionProfile{baseEntry},
 			platenCaps: utils.SourceCapabilities{
-				MaxWidth:       1200,
-				MinWidth:       16,
-				MaxHeight:      2800,
-				MinHeight:      32,
-				MaxScanRegions: 2,
 				SettingProfile: utils.SettingProfile{
-					Name:            "",
-					Ref:             "",
 					ColorModes:      []string{"RGB24"},
-					DocumentFormats: []string{"application/octet-stream"},
+					DocumentFormats: []string{"image/jpeg"},
 					SupportedResolutions: utils.SupportedResolutions{
-						XResolutionRange: utils.ResolutionRange{
-							Min:    65,
-							Max:    85,
-							Normal: 75,
-							Step:   10},
-						YResolutionRange: utils.ResolutionRange{
-							Min:    60,
-							Max:    105,
-							Normal: 90,
-							Step:   15}}},
-				MaxOpticalXResolution: 85,
-				MaxOpticalYResolution: 105,
-				MaxPhysicalWidth:      1200,
-				MaxPhysicalHeight:     2800},
-			// Should pass: [300, 300] is an allowed discrete resolution.
-			adfSimplexCaps: utils.SourceCapabilities{
-				MaxWidth:       1200,
-				MinWidth:       16,
-				MaxHeight:      2800,
-				MinHeight:      32,
-				MaxScanRegions: 2,
+						DiscreteResolutions: []utils.DiscreteResolution{
+							{XResolution: 100, YResolution: 200},
+							{XResolution: 300, YResolution: 300},
+						},
+					},
+				},
+				MaxOpticalXResolution: 600,
+				MaxOpticalYResolution: 600,
+			},
+			numFailures: 0,
+		},
+		{
+			// Should fail: 300x300 is discrete but beyond the optical
+			// limit, i.e. only reachable by digital upsampling.
+			profile: ResolutionProfile{baseEntry},
+			platenCaps: utils.SourceCapabilities{
 				SettingProfile: utils.SettingProfile{
-					Name:            "",
-					Ref:             "",
 					ColorModes:      []string{"RGB24"},
-					DocumentFormats: []string{"application/octet-stream"},
+					DocumentFormats: []string{"image/jpeg"},
 					SupportedResolutions: utils.SupportedResolutions{
-						DiscreteResolutions: []utils.DiscreteResolution{
-							utils.DiscreteResolution{
-								XResolution: 100,
-								YResolution: 200},
-							utils.DiscreteResolution{
-								XResolution: 300,
-								YResolution: 300}}}},
-				MaxOpticalXResolution: 800,
-				MaxOpticalYResolution: 1200,
-				MaxPhysicalWidth:      1200,
-				MaxPhysicalHeight:     2800},
-			// Should pass: zero-value SourceCapabilities aren't checked.
-			adfDuplexCaps: utils.SourceCapabilities{},
-			failures:      []utils.FailureType{},
+						DiscreteResolutions: []utils.DiscreteResolution{{XResolution: 300, YResolution: 300}},
+					},
+				},
+				MaxOpticalXResolution: 150,
+				MaxOpticalYResolution: 150,
+			},
+			numFailures: 1,
+		},
+		{
+			// Should pass: 300 lands exactly on a range step.
+			profile: ResolutionProfile{baseEntry},
+			platenCaps: utils.SourceCapabilities{
+				SettingProfile: utils.SettingProfile{
+					ColorModes:      []string{"RGB24"},
+					DocumentFormats: []string{"image/jpeg"},
+					SupportedResolutions: utils.SupportedResolutions{
+						XResolutionRange: utils.ResolutionRange{Min: 100, Max: 600, Step: 50},
+						YResolutionRange: utils.ResolutionRange{Min: 100, Max: 600, Step: 50},
+					},
+				},
+			},
+			numFailures: 0,
 		},
 		{
-			// Should fail: no resolutions specified for non-zero-value struct.
+			// Should pass: 300x300 is discrete and caps doesn't
+			// advertise an optical limit at all (the common case for
+			// real ScannerCapabilities responses), so the omission
+			// shouldn't be treated as "nothing is reachable".
+			profile: ResolutionProfile{baseEntry},
 			platenCaps: utils.SourceCapabilities{
-				MaxWidth:       1200,
-				MinWidth:       16,
-				MaxHeight:      2800,
-				MinHeight:      32,
-				MaxScanRegions: 2,
 				SettingProfile: utils.SettingProfile{
-					Name:            "",
-					Ref:             "",
 					ColorModes:      []string{"RGB24"},
-					DocumentFormats: []string{"application/octet-stream"},
+					DocumentFormats: []string{"image/jpeg"},
 					SupportedResolutions: utils.SupportedResolutions{
-						XResolutionRange: utils.ResolutionRange{},
-						YResolutionRange: utils.ResolutionRange{}}},
-				MaxOpticalXResolution: 85,
-				MaxOpticalYResolution: 105,
-				MaxPhysicalWidth:      1200,
-				MaxPhysicalHeight:     2800},
-			// Should fail: no matching allowable X and Y resolutions.
-			adfSimplexCaps: utils.SourceCapabilities{
-				MaxWidth:       1200,
-				MinWidth:       16,
-				MaxHeight:      2800,
-				MinHeight:      32,
-				MaxScanRegions: 2,
+						DiscreteResolutions: []utils.DiscreteResolution{{XResolution: 300, YResolution: 300}},
+					},
+				},
+			},
+			numFailures: 0,
+		},
+		{
+			// Should fail: 300 lands on a range step but beyond the
+			// optical limit, i.e. the same digital-upsampling concern as
+			// the discrete case, applied symmetrically to the range
+			// branch.
+			profile: ResolutionProfile{baseEntry},
+			platenCaps: utils.SourceCapabilities{
 				SettingProfile: utils.SettingProfile{
-					Name:            "",
-					Ref:             "",
 					ColorModes:      []string{"RGB24"},
-					DocumentFormats: []string{"application/octet-stream"},
+					DocumentFormats: []string{"image/jpeg"},
 					SupportedResolutions: utils.SupportedResolutions{
-						DiscreteResolutions: []utils.DiscreteResolution{
-							utils.DiscreteResolution{
-								XResolution: 100,
-								YResolution: 200},
-							utils.DiscreteResolution{
-								XResolution: 1200,
-								YResolution: 1200}}}},
-				MaxOpticalXResolution: 800,
-				MaxOpticalYResolution: 1200,
-				MaxPhysicalWidth:      1200,
-				MaxPhysicalHeight:     2800},
-			// Should fail: X and Y resolution ranges do not overlap.
-			adfDuplexCaps: utils.SourceCapabilities{
-				MaxWidth:       1200,
-				MinWidth:       16,
-				MaxHeight:      2800,
-				MinHeight:      32,
-				MaxScanRegions: 2,
+						XResolutionRange: utils.ResolutionRange{Min: 100, Max: 600, Step: 50},
+						YResolutionRange: utils.ResolutionRange{Min: 100, Max: 600, Step: 50},
+					},
+				},
+				MaxOpticalXResolution: 150,
+				MaxOpticalYResolution: 150,
+			},
+			numFailures: 1,
+		},
+		{
+			// Should fail: no matching color mode.
+			profile: ResolutionProfile{baseEntry},
+			platenCaps: utils.SourceCapabilities{
+				SettingProfile: utils.SettingProfile{
+					ColorModes:      []string{"Grayscale8"},
+					DocumentFormats: []string{"image/jpeg"},
+					SupportedResolutions: utils.SupportedResolutions{
+						DiscreteResolutions: []utils.DiscreteResolution{{XResolution: 300, YResolution: 300}},
+					},
+				},
+			},
+			numFailures: 1,
+		},
+		{
+			// Should fail: platen has no source capabilities at all.
+			profile:     ResolutionProfile{baseEntry},
+			platenCaps:  utils.SourceCapabilities{},
+			numFailures: 1,
+		},
+		{
+			// Should fail: stepping by 40 from 110 never lands on 300.
+			profile: ResolutionProfile{baseEntry},
+			platenCaps: utils.SourceCapabilities{
+				SettingProfile: utils.SettingProfile{
+					ColorModes:      []string{"RGB24"},
+					DocumentFormats: []string{"image/jpeg"},
+					SupportedResolutions: utils.SupportedResolutions{
+						XResolutionRange: utils.ResolutionRange{Min: 110, Max: 600, Step: 40},
+						YResolutionRange: utils.ResolutionRange{Min: 110, Max: 600, Step: 40},
+					},
+				},
+			},
+			numFailures: 1,
+		},
+		{
+			// Multiple rows across two sources: platen satisfied,
+			// adf_simplex missing entirely.
+			profile: ResolutionProfile{
+				baseEntry,
+				{Source: "adf_simplex", X: 300, Y: 300, ColorMode: "RGB24", Format: "image/jpeg", Method: Exact},
+			},
+			platenCaps: utils.SourceCapabilities{
 				SettingProfile: utils.SettingProfile{
-					Name:            "",
-					Ref:             "",
 					ColorModes:      []string{"RGB24"},
-					DocumentFormats: []string{"application/octet-stream"},
+					DocumentFormats: []string{"image/jpeg"},
 					SupportedResolutions: utils.SupportedResolutions{
-						XResolutionRange: utils.ResolutionRange{
-							Min:    65,
-							Max:    85,
-							Normal: 75,
-							Step:   10},
-						YResolutionRange: utils.ResolutionRange{
-							Min:    200,
-							Max:    600,
-							Normal: 300,
-							Step:   100}}},
-				MaxOpticalXResolution: 85,
+						DiscreteResolutions: []utils.DiscreteResolution{{XResolution: 300, YResolution: 300}},
+					},
+				},
+				MaxOpticalXResolution: 600,
 				MaxOpticalYResolution: 600,
-				MaxPhysicalWidth:      1200,
-				MaxPhysicalHeight:     2800},
-			failures: []utils.FailureType{utils.CriticalFailure, utils.CriticalFailure, utils.CriticalFailure},
+			},
+			adfSimplex:  utils.SourceCapabilities{},
+			numFailures: 1,
 		},
 	}
 
-	for _, tc := range tests {
-		got, err := HasSupportedResolutionTest(tc.platenCaps, tc.adfSimplexCaps, tc.adfDuplexCaps)()
-
+	for i, tc := range tests {
+		sourceCaps := map[string]utils.SourceCapabilities{
+			"platen":      tc.platenCaps,
+			"adf_simplex": tc.adfSimplex,
+		}
+		got, err := HasSupportedResolutionTest(tc.profile, sourceCaps)()
 		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
 		}
-
-		if len(got) != len(tc.failures) {
-			t.Errorf("Number of failures: expected %d, got %d", len(tc.failures), len(got))
+		if len(got) != tc.numFailures {
+			t.Errorf("case %d: number of failures: expected %d, got %d (%v)", i, tc.numFailures, len(got), got)
 		}
-		for i, failure := range got {
-			if failure.Type != tc.failures[i] {
-				t.Errorf("FailureType: expected %d, got %d", tc.failures[i], failure.Type)
+		for _, failure := range got {
+			if failure.Type != utils.CriticalFailure {
+				t.Errorf("case %d: FailureType: expected %v, got %v", i, utils.CriticalFailure, failure.Type)
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestHasSupportedResolutionTestNearestStep(t *testing.T) {
+	entry := ResolutionProfileEntry{
+		Source: "platen", X: 300, Y: 300, ColorMode: "RGB24", Format: "image/jpeg", Method: NearestStep,
+	}
+	caps := utils.SourceCapabilities{
+		SettingProfile: utils.SettingProfile{
+			ColorModes:      []string{"RGB24"},
+			DocumentFormats: []string{"image/jpeg"},
+			SupportedResolutions: utils.SupportedResolutions{
+				// Closest reachable step to 300 is 296, within the
+				// default nearest-step tolerance.
+				XResolutionRange: utils.ResolutionRange{Min: 100, Max: 600, Step: 49},
+				YResolutionRange: utils.ResolutionRange{Min: 100, Max: 600, Step: 49},
+			},
+		},
+	}
+
+	got, err := HasSupportedResolutionTest(ResolutionProfile{entry}, map[string]utils.SourceCapabilities{"platen": caps})()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("failures = %v, want none", got)
+	}
+}
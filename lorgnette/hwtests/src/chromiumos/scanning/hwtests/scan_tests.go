@@ -0,0 +1,202 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package hwtests
+
+import (
+	"fmt"
+
+	"chromiumos/scanning/escl"
+	"chromiumos/scanning/imagedecode"
+	"chromiumos/scanning/utils"
+)
+
+// supportedScanFormats are the DocumentFormats ScanAndDecodeTest knows
+// how to decode and verify. A source may advertise other formats; those
+// are skipped rather than failed, since we can't check what we can't
+// decode.
+var supportedScanFormats = []string{"image/jpeg", "image/png", "application/octet-stream"}
+
+// dpiTolerance allows for the rounding a format's DPI metadata goes
+// through on the way from pixels-per-inch to the format's native units
+// (e.g. BMP and PNG both store pixels per meter) and back.
+const dpiTolerance = 1
+
+// pixelTolerance allows for the same kind of rounding when converting a
+// source's advertised scan area, in eSCL's three-hundredths-of-an-inch
+// units, to pixels at the requested resolution.
+const pixelTolerance = 1
+
+// ScanAndDecodeTest returns a hwtest that, for every resolution caps
+// advertises for source (every DiscreteResolution and a sample of
+// points from every ResolutionRange), issues a real eSCL scan at that
+// resolution in each supported document format and decodes the result,
+// checking the decoded image's pixel dimensions and embedded DPI
+// metadata against what caps promised. This catches a firmware that
+// lies about a capability in its ScannerCapabilities XML rather than
+// just linting that XML.
+func ScanAndDecodeTest(client escl.Client, source string, caps utils.SourceCapabilities) func() ([]utils.Failure, error) {
+	return func() ([]utils.Failure, error) {
+		if caps.IsZero() {
+			return nil, nil
+		}
+
+		colorMode := "RGB24"
+		if len(caps.SettingProfile.ColorModes) > 0 {
+			colorMode = caps.SettingProfile.ColorModes[0]
+		}
+
+		var failures []utils.Failure
+		for _, resolution := range scanResolutions(caps.SettingProfile.SupportedResolutions) {
+			for _, format := range caps.SettingProfile.DocumentFormats {
+				if !isSupportedScanFormat(format) {
+					continue
+				}
+
+				failure, err := scanAndVerify(client, source, caps, resolution, colorMode, format)
+				if err != nil {
+					return nil, fmt.Errorf("scanning %s at %dx%d %s: %w", source, resolution.XResolution, resolution.YResolution, format, err)
+				}
+				if failure != nil {
+					failures = append(failures, *failure)
+				}
+			}
+		}
+		return failures, nil
+	}
+}
+
+// scanAndVerify issues one scan and returns a Failure describing any
+// mismatch between the decoded document and what caps promised, or nil
+// if the decoded document matched.
+func scanAndVerify(client escl.Client, source string, caps utils.SourceCapabilities, resolution utils.DiscreteResolution, colorMode, format string) (*utils.Failure, error) {
+	inputSource, duplex, err := esclInputSource(source)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := client.Scan(escl.ScanSettings{
+		Source:      inputSource,
+		Duplex:      duplex,
+		XResolution: resolution.XResolution,
+		YResolution: resolution.YResolution,
+		ColorMode:   colorMode,
+		Format:      format,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := imagedecode.Decode(format, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	wantWidth := scaleToResolution(caps.MaxWidth, resolution.XResolution)
+	wantHeight := scaleToResolution(caps.MaxHeight, resolution.YResolution)
+
+	var mismatches []string
+	if !withinTolerance(decoded.Width, wantWidth, pixelTolerance) {
+		mismatches = append(mismatches, fmt.Sprintf("width %d, want %d", decoded.Width, wantWidth))
+	}
+	if !withinTolerance(decoded.Height, wantHeight, pixelTolerance) {
+		mismatches = append(mismatches, fmt.Sprintf("height %d, want %d", decoded.Height, wantHeight))
+	}
+	if decoded.XDPI != 0 && !withinTolerance(decoded.XDPI, resolution.XResolution, dpiTolerance) {
+		mismatches = append(mismatches, fmt.Sprintf("x DPI %d, want %d", decoded.XDPI, resolution.XResolution))
+	}
+	if decoded.YDPI != 0 && !withinTolerance(decoded.YDPI, resolution.YResolution, dpiTolerance) {
+		mismatches = append(mismatches, fmt.Sprintf("y DPI %d, want %d", decoded.YDPI, resolution.YResolution))
+	}
+
+	if len(mismatches) == 0 {
+		return nil, nil
+	}
+	return &utils.Failure{
+		Type: utils.CriticalFailure,
+		Message: fmt.Sprintf("%s %dx%d %s: decoded document doesn't match advertised capabilities: %s",
+			source, resolution.XResolution, resolution.YResolution, format, mismatches),
+	}, nil
+}
+
+// esclInputSource maps a hwtests source key (an eSCL ScannerCapabilities
+// source, as used in the caps map keyed by "platen", "adf_simplex" and
+// "adf_duplex") to the eSCL InputSource token and Duplex flag a ScanJobs
+// request must use. eSCL only defines "Platen", "Feeder" and "Camera"
+// for InputSource; there's no separate ADF-duplex token, so adf_duplex
+// also maps to "Feeder", with Duplex set.
+func esclInputSource(source string) (inputSource string, duplex bool, err error) {
+	switch source {
+	case "platen":
+		return "Platen", false, nil
+	case "adf_simplex":
+		return "Feeder", false, nil
+	case "adf_duplex":
+		return "Feeder", true, nil
+	default:
+		return "", false, fmt.Errorf("unknown source %q", source)
+	}
+}
+
+// scaleToResolution converts maxDimension, in eSCL's three-hundredths-
+// of-an-inch units, to the pixel count it should produce at resolution
+// pixels per inch.
+func scaleToResolution(maxDimension, resolution int) int {
+	return (maxDimension*resolution + 150) / 300
+}
+
+func withinTolerance(got, want, tolerance int) bool {
+	diff := got - want
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= tolerance
+}
+
+func isSupportedScanFormat(format string) bool {
+	for _, f := range supportedScanFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// scanResolutions returns every resolution to exercise for a source:
+// each DiscreteResolution as-is, plus a sample of points from the X and Y
+// ResolutionRanges (their minimum, their normal default, and their
+// maximum, paired up point-by-point rather than every integer step),
+// since a real scan is far more expensive than a capability check. If
+// either range is unset, no range-based points are sampled, since X and Y
+// must come from the same conceptual point (e.g. both Min) to be a
+// resolution the scanner actually advertises.
+func scanResolutions(res utils.SupportedResolutions) []utils.DiscreteResolution {
+	resolutions := append([]utils.DiscreteResolution{}, res.DiscreteResolutions...)
+
+	seen := map[utils.DiscreteResolution]bool{}
+	for _, point := range rangeSamplePoints(res.XResolutionRange, res.YResolutionRange) {
+		if !seen[point] {
+			seen[point] = true
+			resolutions = append(resolutions, point)
+		}
+	}
+	return resolutions
+}
+
+// rangeSamplePoints returns the minimum, normal default and maximum
+// points of xRange and yRange paired up axis-by-axis (Min with Min,
+// Normal with Normal, Max with Max), or nil if either range is unset,
+// since a point like (xRange.Max, yRange.Min) isn't necessarily a
+// resolution the scanner can actually produce.
+func rangeSamplePoints(xRange, yRange utils.ResolutionRange) []utils.DiscreteResolution {
+	if xRange == (utils.ResolutionRange{}) || yRange == (utils.ResolutionRange{}) {
+		return nil
+	}
+
+	return []utils.DiscreteResolution{
+		{XResolution: xRange.Min, YResolution: yRange.Min},
+		{XResolution: xRange.Normal, YResolution: yRange.Normal},
+		{XResolution: xRange.Max, YResolution: yRange.Max},
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package hwtests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"chromiumos/scanning/escl"
+	"chromiumos/scanning/utils"
+)
+
+// fakeClient is an escl.Client that returns a canned, correctly-sized
+// BMP for any ScanSettings, so ScanAndDecodeTest can be exercised
+// without a real scanner. It records the last settings it was asked to
+// scan, so a test can assert what ScanAndDecodeTest actually requested.
+type fakeClient struct {
+	widthPx, heightPx int
+	xDPI, yDPI        int
+
+	lastSettings *escl.ScanSettings
+}
+
+func (f *fakeClient) Scan(settings escl.ScanSettings) ([]byte, error) {
+	f.lastSettings = &settings
+	return makeTestBMP(f.widthPx, f.heightPx, f.xDPI, f.yDPI), nil
+}
+
+// makeTestBMP builds a minimal, uncompressed, top-down, 24-bit BMP of
+// the given pixel size and DPI.
+func makeTestBMP(width, height, xDPI, yDPI int) []byte {
+	const metersPerInch = 39.3701
+	rowSize := ((24*width + 31) / 32) * 4
+	dataOffset := 14 + 40
+	pixelData := make([]byte, rowSize*height)
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("BM")
+	binary.Write(buf, binary.LittleEndian, uint32(dataOffset+len(pixelData)))
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+	binary.Write(buf, binary.LittleEndian, uint32(dataOffset))
+
+	binary.Write(buf, binary.LittleEndian, uint32(40))
+	binary.Write(buf, binary.LittleEndian, uint32(width))
+	binary.Write(buf, binary.LittleEndian, int32(-height)) // top-down
+	binary.Write(buf, binary.LittleEndian, uint16(1))
+	binary.Write(buf, binary.LittleEndian, uint16(24))
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+	binary.Write(buf, binary.LittleEndian, uint32(len(pixelData)))
+	binary.Write(buf, binary.LittleEndian, int32(float64(xDPI)*metersPerInch))
+	binary.Write(buf, binary.LittleEndian, int32(float64(yDPI)*metersPerInch))
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+
+	buf.Write(pixelData)
+	return buf.Bytes()
+}
+
+func testCaps() utils.SourceCapabilities {
+	return utils.SourceCapabilities{
+		MaxWidth:  1200,
+		MaxHeight: 2800,
+		SettingProfile: utils.SettingProfile{
+			ColorModes:      []string{"RGB24"},
+			DocumentFormats: []string{"application/octet-stream"},
+			SupportedResolutions: utils.SupportedResolutions{
+				DiscreteResolutions: []utils.DiscreteResolution{{XResolution: 300, YResolution: 300}},
+			},
+		},
+	}
+}
+
+func TestScanAndDecodeTestPasses(t *testing.T) {
+	client := &fakeClient{widthPx: 1200, heightPx: 2800, xDPI: 300, yDPI: 300}
+	got, err := ScanAndDecodeTest(client, "platen", testCaps())()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("failures = %v, want none", got)
+	}
+}
+
+func TestScanAndDecodeTestCatchesWrongDimensions(t *testing.T) {
+	// Firmware claims 300x300 but the decoded document is sized as if it
+	// scanned at a lower resolution.
+	client := &fakeClient{widthPx: 600, heightPx: 1400, xDPI: 300, yDPI: 300}
+	got, err := ScanAndDecodeTest(client, "platen", testCaps())()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("failures = %v, want exactly 1", got)
+	}
+	if got[0].Type != utils.CriticalFailure {
+		t.Errorf("failure type = %v, want CriticalFailure", got[0].Type)
+	}
+}
+
+func TestScanAndDecodeTestSkipsZeroCapabilities(t *testing.T) {
+	client := &fakeClient{}
+	got, err := ScanAndDecodeTest(client, "adf_duplex", utils.SourceCapabilities{})()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("failures = %v, want none", got)
+	}
+}
+
+// TestScanAndDecodeTestMapsSource verifies that ScanAndDecodeTest
+// translates a hwtests source key into the eSCL InputSource token (and
+// Duplex flag) a real scanner expects, rather than passing the caps-map
+// key straight through.
+func TestScanAndDecodeTestMapsSource(t *testing.T) {
+	tests := []struct {
+		source          string
+		wantInputSource string
+		wantDuplex      bool
+	}{
+		{"platen", "Platen", false},
+		{"adf_simplex", "Feeder", false},
+		{"adf_duplex", "Feeder", true},
+	}
+
+	for _, tc := range tests {
+		client := &fakeClient{widthPx: 1200, heightPx: 2800, xDPI: 300, yDPI: 300}
+		if _, err := ScanAndDecodeTest(client, tc.source, testCaps())(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.source, err)
+		}
+		if client.lastSettings == nil {
+			t.Fatalf("%s: client.Scan was never called", tc.source)
+		}
+		if client.lastSettings.Source != tc.wantInputSource {
+			t.Errorf("%s: Source = %q, want %q", tc.source, client.lastSettings.Source, tc.wantInputSource)
+		}
+		if client.lastSettings.Duplex != tc.wantDuplex {
+			t.Errorf("%s: Duplex = %v, want %v", tc.source, client.lastSettings.Duplex, tc.wantDuplex)
+		}
+	}
+}
+
+func TestEsclInputSource(t *testing.T) {
+	tests := []struct {
+		source          string
+		wantInputSource string
+		wantDuplex      bool
+		wantErr         bool
+	}{
+		{source: "platen", wantInputSource: "Platen"},
+		{source: "adf_simplex", wantInputSource: "Feeder"},
+		{source: "adf_duplex", wantInputSource: "Feeder", wantDuplex: true},
+		{source: "flatbed", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		inputSource, duplex, err := esclInputSource(tc.source)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tc.source, err, tc.wantErr)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if inputSource != tc.wantInputSource || duplex != tc.wantDuplex {
+			t.Errorf("%s: esclInputSource = (%q, %v), want (%q, %v)", tc.source, inputSource, duplex, tc.wantInputSource, tc.wantDuplex)
+		}
+	}
+}
+
+func TestScanResolutions(t *testing.T) {
+	res := utils.SupportedResolutions{
+		DiscreteResolutions: []utils.DiscreteResolution{{XResolution: 300, YResolution: 300}},
+		XResolutionRange:    utils.ResolutionRange{Min: 75, Max: 600, Normal: 150},
+		YResolutionRange:    utils.ResolutionRange{Min: 75, Max: 600, Normal: 150},
+	}
+	got := scanResolutions(res)
+	// 1 discrete plus 3 sampled range points (min, normal, max).
+	if len(got) != 4 {
+		t.Fatalf("scanResolutions returned %d resolutions, want 4: %v", len(got), got)
+	}
+}
+
+// TestScanResolutionsDoesNotCrossPair verifies that when the X and Y
+// ranges' sampled points happen to collapse to different numbers of
+// distinct values (because one axis has Min == Normal and the other
+// doesn't), scanResolutions doesn't pair up points from different
+// conceptual positions, which would produce a resolution the scanner
+// never advertised.
+func TestScanResolutionsDoesNotCrossPair(t *testing.T) {
+	res := utils.SupportedResolutions{
+		// X's Min and Normal collapse to the same value (75), so a naive
+		// independent-dedup-then-zip would pair X's 2 distinct values
+		// against Y's 3, producing the bogus pair (600, 150).
+		XResolutionRange: utils.ResolutionRange{Min: 75, Normal: 75, Max: 600},
+		YResolutionRange: utils.ResolutionRange{Min: 75, Normal: 150, Max: 600},
+	}
+	got := scanResolutions(res)
+	for _, r := range got {
+		if r.XResolution == 600 && r.YResolution == 150 {
+			t.Errorf("scanResolutions produced unadvertised pair (600, 150): %v", got)
+		}
+	}
+}
+
+func TestIsSupportedScanFormat(t *testing.T) {
+	if !isSupportedScanFormat("image/jpeg") {
+		t.Error("image/jpeg should be supported")
+	}
+	if isSupportedScanFormat("application/pdf") {
+		t.Error("application/pdf should not be supported")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package hwtests
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SuppressionKind distinguishes a suppression that's a deliberate,
+// permanent exception from one that's only covering a fix in flight.
+type SuppressionKind string
+
+const (
+	// Permanent suppressions require a justification and bug, and never
+	// expire on their own; removing one is a manual, reviewed change.
+	Permanent SuppressionKind = "permanent"
+	// Temporary suppressions carry an expiry date. Once that date has
+	// passed the suppression stops applying and the check it covers
+	// reports at its test's normal tier again.
+	Temporary SuppressionKind = "temporary"
+)
+
+// expiresLayout is the date format suppression files use for Expires.
+const expiresLayout = "2006-01-02"
+
+// Suppression excludes a single named Test from a specific scanner
+// model, optionally restricted to a firmware range, with a reason:
+// either a Permanent, justified exception, or a Temporary one with an
+// expiry date the runner checks on every run.
+type Suppression struct {
+	// Check is the Test.Name this suppression excludes.
+	Check string `yaml:"check"`
+	// Model is the scanner model this suppression applies to.
+	Model string `yaml:"model"`
+	// MinFirmware and MaxFirmware, if set, restrict the suppression to
+	// scanners whose firmware version falls in [MinFirmware,
+	// MaxFirmware], compared component-wise as dotted-numeric versions
+	// (e.g. "1.9.0" < "1.10.0"), not lexically.
+	MinFirmware string `yaml:"min_firmware,omitempty"`
+	MaxFirmware string `yaml:"max_firmware,omitempty"`
+
+	Kind          SuppressionKind `yaml:"kind"`
+	Justification string          `yaml:"justification"`
+	Bug           string          `yaml:"bug"`
+	// Expires is the date, formatted as expiresLayout, after which a
+	// Temporary suppression stops applying. Unused for Permanent.
+	Expires string `yaml:"expires,omitempty"`
+}
+
+// LoadSuppressions parses a suppression list from the YAML file at path.
+func LoadSuppressions(path string) ([]Suppression, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading suppression file: %w", err)
+	}
+
+	var suppressions []Suppression
+	if err := yaml.Unmarshal(b, &suppressions); err != nil {
+		return nil, fmt.Errorf("parsing suppression file: %w", err)
+	}
+
+	for i, s := range suppressions {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("suppression %d: %w", i, err)
+		}
+	}
+	return suppressions, nil
+}
+
+func (s Suppression) validate() error {
+	if s.Check == "" || s.Model == "" {
+		return fmt.Errorf("check and model are required")
+	}
+	if s.Justification == "" || s.Bug == "" {
+		return fmt.Errorf("justification and bug are required")
+	}
+
+	if s.MinFirmware != "" {
+		if _, err := parseFirmwareVersion(s.MinFirmware); err != nil {
+			return fmt.Errorf("min_firmware: %w", err)
+		}
+	}
+	if s.MaxFirmware != "" {
+		if _, err := parseFirmwareVersion(s.MaxFirmware); err != nil {
+			return fmt.Errorf("max_firmware: %w", err)
+		}
+	}
+
+	switch s.Kind {
+	case Permanent:
+		return nil
+	case Temporary:
+		if s.Expires == "" {
+			return fmt.Errorf("temporary suppressions require expires")
+		}
+		if _, err := time.Parse(expiresLayout, s.Expires); err != nil {
+			return fmt.Errorf("invalid expires date: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("kind must be %q or %q, got %q", Permanent, Temporary, s.Kind)
+	}
+}
+
+// matches reports whether s covers the given check name, scanner model
+// and firmware version. A firmware bound is skipped if the scanner's
+// reported firmware doesn't parse as a dotted-numeric version, since a
+// malformed version from the scanner shouldn't silently widen or narrow
+// which scanners a suppression applies to.
+func (s Suppression) matches(check, model, firmware string) bool {
+	if s.Check != check || s.Model != model {
+		return false
+	}
+
+	if s.MinFirmware == "" && s.MaxFirmware == "" {
+		return true
+	}
+	version, err := parseFirmwareVersion(firmware)
+	if err != nil {
+		return false
+	}
+	if s.MinFirmware != "" {
+		// Already validated in validate(), so the error is unreachable.
+		min, _ := parseFirmwareVersion(s.MinFirmware)
+		if compareFirmwareVersions(version, min) < 0 {
+			return false
+		}
+	}
+	if s.MaxFirmware != "" {
+		max, _ := parseFirmwareVersion(s.MaxFirmware)
+		if compareFirmwareVersions(version, max) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFirmwareVersion parses a dotted-numeric firmware version like
+// "1.9.0" into its component integers.
+func parseFirmwareVersion(version string) ([]int, error) {
+	parts := strings.Split(version, ".")
+	components := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("firmware version %q: component %q isn't a non-negative integer", version, part)
+		}
+		components[i] = n
+	}
+	return components, nil
+}
+
+// compareFirmwareVersions compares two parsed firmware versions
+// component-wise, returning -1, 0 or 1 as a < b, a == b or a > b. A
+// version with fewer components than the other is padded with zeros
+// (e.g. "1.9" == "1.9.0").
+func compareFirmwareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// active reports whether s still applies at now: a Temporary suppression
+// stops applying once its expiry date has passed, at which point the
+// check it covers is re-promoted to its normal tier.
+func (s Suppression) active(now time.Time) bool {
+	if s.Kind != Temporary {
+		return true
+	}
+	expires, err := time.Parse(expiresLayout, s.Expires)
+	if err != nil {
+		return false
+	}
+	return now.Before(expires)
+}
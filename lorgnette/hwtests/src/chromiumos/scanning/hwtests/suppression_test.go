@@ -0,0 +1,142 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package hwtests
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuppressionMatches(t *testing.T) {
+	s := Suppression{
+		Check:       "HasSupportedResolutionTest",
+		Model:       "Generic Scanner 1000",
+		MinFirmware: "1.0.0",
+		MaxFirmware: "1.5.0",
+	}
+
+	tests := []struct {
+		check, model, firmware string
+		want                   bool
+	}{
+		{"HasSupportedResolutionTest", "Generic Scanner 1000", "1.2.0", true},
+		{"HasSupportedResolutionTest", "Generic Scanner 1000", "1.0.0", true},
+		{"HasSupportedResolutionTest", "Generic Scanner 1000", "1.5.0", true},
+		{"OtherTest", "Generic Scanner 1000", "1.2.0", false},
+		{"HasSupportedResolutionTest", "Other Scanner", "1.2.0", false},
+		{"HasSupportedResolutionTest", "Generic Scanner 1000", "0.9.0", false},
+		{"HasSupportedResolutionTest", "Generic Scanner 1000", "1.6.0", false},
+	}
+
+	for _, tc := range tests {
+		if got := s.matches(tc.check, tc.model, tc.firmware); got != tc.want {
+			t.Errorf("matches(%q, %q, %q) = %v, want %v", tc.check, tc.model, tc.firmware, got, tc.want)
+		}
+	}
+}
+
+// TestSuppressionMatchesFirmwareVersionOrdering verifies that firmware
+// bounds are compared as dotted-numeric versions, not lexically, where
+// e.g. "1.9.0" < "1.10.0" even though it sorts after it as a string.
+func TestSuppressionMatchesFirmwareVersionOrdering(t *testing.T) {
+	s := Suppression{
+		Check:       "HasSupportedResolutionTest",
+		Model:       "Generic Scanner 1000",
+		MinFirmware: "1.9.0",
+	}
+	// 1.10.0 is numerically newer than 1.9.0, so it should match the
+	// min_firmware bound even though "1.10.0" < "1.9.0" under plain
+	// string comparison.
+	if !s.matches("HasSupportedResolutionTest", "Generic Scanner 1000", "1.10.0") {
+		t.Error("matches(..., \"1.10.0\") = false, want true: 1.10.0 is newer than min_firmware 1.9.0")
+	}
+	if s.matches("HasSupportedResolutionTest", "Generic Scanner 1000", "1.8.0") {
+		t.Error("matches(..., \"1.8.0\") = true, want false: 1.8.0 is older than min_firmware 1.9.0")
+	}
+}
+
+func TestSuppressionActive(t *testing.T) {
+	now := time.Date(2026, time.July, 26, 0, 0, 0, 0, time.UTC)
+
+	permanent := Suppression{Kind: Permanent}
+	if !permanent.active(now) {
+		t.Error("Permanent suppression should always be active")
+	}
+
+	notYetExpired := Suppression{Kind: Temporary, Expires: "2026-08-01"}
+	if !notYetExpired.active(now) {
+		t.Error("Temporary suppression should be active before its expiry date")
+	}
+
+	expired := Suppression{Kind: Temporary, Expires: "2026-07-01"}
+	if expired.active(now) {
+		t.Error("Temporary suppression should not be active after its expiry date")
+	}
+}
+
+func TestSuppressionValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Suppression
+		wantErr bool
+	}{
+		{
+			name: "valid permanent",
+			s: Suppression{
+				Check: "HasSupportedResolutionTest", Model: "Generic Scanner 1000",
+				Kind: Permanent, Justification: "known hardware limitation", Bug: "b/123456",
+			},
+		},
+		{
+			name: "valid temporary",
+			s: Suppression{
+				Check: "HasSupportedResolutionTest", Model: "Generic Scanner 1000",
+				Kind: Temporary, Justification: "fix in progress", Bug: "b/123456", Expires: "2026-12-31",
+			},
+		},
+		{
+			name:    "missing model",
+			s:       Suppression{Check: "HasSupportedResolutionTest", Kind: Permanent, Justification: "x", Bug: "b/1"},
+			wantErr: true,
+		},
+		{
+			name:    "missing justification",
+			s:       Suppression{Check: "HasSupportedResolutionTest", Model: "Generic Scanner 1000", Kind: Permanent, Bug: "b/1"},
+			wantErr: true,
+		},
+		{
+			name: "temporary missing expires",
+			s: Suppression{
+				Check: "HasSupportedResolutionTest", Model: "Generic Scanner 1000",
+				Kind: Temporary, Justification: "x", Bug: "b/1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid kind",
+			s: Suppression{
+				Check: "HasSupportedResolutionTest", Model: "Generic Scanner 1000",
+				Kind: "sometimes", Justification: "x", Bug: "b/1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed max_firmware",
+			s: Suppression{
+				Check: "HasSupportedResolutionTest", Model: "Generic Scanner 1000",
+				MaxFirmware: "not-a-version",
+				Kind:        Permanent, Justification: "x", Bug: "b/1",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		err := tc.s.validate()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: validate() error = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package bmp decodes the Windows BITMAPINFOHEADER bitmaps that some
+// scanner firmwares return when they advertise the document format as
+// application/octet-stream. It covers the layouts actually seen in the
+// field: top-down and bottom-up row order, and 8-bit palette, 24-bit and
+// 32-bit pixel data.
+package bmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+const (
+	fileHeaderSize = 14
+	infoHeaderSize = 40
+)
+
+// Decode parses a BMP image and returns the decoded pixels together with
+// the resolution it was saved at, in pixels per inch, derived from the
+// header's pixels-per-meter fields. xDPI or yDPI is 0 if the header
+// doesn't specify a resolution for that axis.
+func Decode(data []byte) (img image.Image, xDPI, yDPI int, err error) {
+	if len(data) < fileHeaderSize+infoHeaderSize {
+		return nil, 0, 0, fmt.Errorf("bmp: file too short: %d bytes", len(data))
+	}
+	if data[0] != 'B' || data[1] != 'M' {
+		return nil, 0, 0, fmt.Errorf("bmp: bad signature %q", data[0:2])
+	}
+
+	dataOffset := binary.LittleEndian.Uint32(data[10:14])
+	headerSize := binary.LittleEndian.Uint32(data[14:18])
+	if headerSize < infoHeaderSize {
+		return nil, 0, 0, fmt.Errorf("bmp: unsupported DIB header size %d", headerSize)
+	}
+
+	width := int(int32(binary.LittleEndian.Uint32(data[18:22])))
+	rawHeight := int32(binary.LittleEndian.Uint32(data[22:26]))
+	bitCount := binary.LittleEndian.Uint16(data[28:30])
+	compression := binary.LittleEndian.Uint32(data[30:34])
+	xPelsPerMeter := int32(binary.LittleEndian.Uint32(data[38:42]))
+	yPelsPerMeter := int32(binary.LittleEndian.Uint32(data[42:46]))
+
+	if compression != 0 {
+		return nil, 0, 0, fmt.Errorf("bmp: unsupported compression %d", compression)
+	}
+
+	// A negative height means the rows are stored top-down; positive
+	// means bottom-up, which is BMP's traditional row order.
+	topDown := rawHeight < 0
+	height := int(rawHeight)
+	if topDown {
+		height = -height
+	}
+	if width <= 0 || height <= 0 {
+		return nil, 0, 0, fmt.Errorf("bmp: invalid dimensions %dx%d", width, height)
+	}
+
+	var palette color.Palette
+	paletteStart := fileHeaderSize + int(headerSize)
+	if bitCount <= 8 {
+		numColors := (int(dataOffset) - paletteStart) / 4
+		if numColors <= 0 {
+			return nil, 0, 0, fmt.Errorf("bmp: palette missing for %d-bit image", bitCount)
+		}
+		palette = make(color.Palette, numColors)
+		for i := 0; i < numColors; i++ {
+			off := paletteStart + i*4
+			if off+4 > len(data) {
+				return nil, 0, 0, fmt.Errorf("bmp: palette truncated")
+			}
+			// Palette entries are stored BGRX.
+			palette[i] = color.RGBA{R: data[off+2], G: data[off+1], B: data[off], A: 0xff}
+		}
+	}
+
+	if int(dataOffset) > len(data) {
+		return nil, 0, 0, fmt.Errorf("bmp: data offset %d beyond file length %d", dataOffset, len(data))
+	}
+
+	rowSize := ((int(bitCount)*width + 31) / 32) * 4
+	pixels := data[dataOffset:]
+
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+	for row := 0; row < height; row++ {
+		srcRow := row
+		if !topDown {
+			srcRow = height - 1 - row
+		}
+		rowStart := srcRow * rowSize
+		if rowStart+rowSize > len(pixels) {
+			return nil, 0, 0, fmt.Errorf("bmp: pixel data truncated at row %d", row)
+		}
+		rowData := pixels[rowStart : rowStart+rowSize]
+
+		for col := 0; col < width; col++ {
+			var c color.RGBA
+			switch bitCount {
+			case 8:
+				index := rowData[col]
+				if int(index) >= len(palette) {
+					return nil, 0, 0, fmt.Errorf("bmp: palette index %d out of range (palette has %d entries)", index, len(palette))
+				}
+				c = palette[index].(color.RGBA)
+			case 24:
+				off := col * 3
+				c = color.RGBA{R: rowData[off+2], G: rowData[off+1], B: rowData[off], A: 0xff}
+			case 32:
+				off := col * 4
+				c = color.RGBA{R: rowData[off+2], G: rowData[off+1], B: rowData[off], A: 0xff}
+			default:
+				return nil, 0, 0, fmt.Errorf("bmp: unsupported bit depth %d", bitCount)
+			}
+			rgba.SetRGBA(col, row, c)
+		}
+	}
+
+	return rgba, pelsPerMeterToDPI(xPelsPerMeter), pelsPerMeterToDPI(yPelsPerMeter), nil
+}
+
+// pelsPerMeterToDPI converts a BITMAPINFOHEADER pixels-per-meter value
+// to pixels per inch, rounding to the nearest integer.
+func pelsPerMeterToDPI(pelsPerMeter int32) int {
+	if pelsPerMeter <= 0 {
+		return 0
+	}
+	const metersPerInch = 39.3701
+	return int(float64(pelsPerMeter)/metersPerInch + 0.5)
+}
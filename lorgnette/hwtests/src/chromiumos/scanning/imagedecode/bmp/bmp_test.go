@@ -0,0 +1,186 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package bmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+// makeBMP builds a minimal, uncompressed BITMAPINFOHEADER bitmap with
+// the given bit depth and row order, for 2x2 test images. rows is
+// top-to-bottom regardless of bitTopDown; this function handles writing
+// them in the requested on-disk order.
+func makeBMP(t *testing.T, bitCount uint16, topDown bool, rows [][]color.RGBA, palette []color.RGBA) []byte {
+	t.Helper()
+
+	width, height := 2, 2
+	rowSize := ((int(bitCount)*width + 31) / 32) * 4
+
+	var paletteBytes []byte
+	for _, c := range palette {
+		paletteBytes = append(paletteBytes, c.B, c.G, c.R, 0)
+	}
+
+	dataOffset := fileHeaderSize + infoHeaderSize + len(paletteBytes)
+
+	onDiskRows := make([][]color.RGBA, len(rows))
+	copy(onDiskRows, rows)
+	if !topDown {
+		for i, j := 0, len(onDiskRows)-1; i < j; i, j = i+1, j-1 {
+			onDiskRows[i], onDiskRows[j] = onDiskRows[j], onDiskRows[i]
+		}
+	}
+
+	var pixelData []byte
+	for _, row := range onDiskRows {
+		rowStart := len(pixelData)
+		for _, c := range row {
+			switch bitCount {
+			case 24:
+				pixelData = append(pixelData, c.B, c.G, c.R)
+			case 32:
+				pixelData = append(pixelData, c.B, c.G, c.R, c.A)
+			case 8:
+				pixelData = append(pixelData, paletteIndex(palette, c))
+			}
+		}
+		for len(pixelData)-rowStart < rowSize {
+			pixelData = append(pixelData, 0)
+		}
+	}
+
+	signedHeight := int32(height)
+	if topDown {
+		signedHeight = -signedHeight
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("BM")
+	binary.Write(buf, binary.LittleEndian, uint32(dataOffset+len(pixelData))) // file size
+	binary.Write(buf, binary.LittleEndian, uint32(0))                         // reserved
+	binary.Write(buf, binary.LittleEndian, uint32(dataOffset))
+
+	binary.Write(buf, binary.LittleEndian, uint32(infoHeaderSize))
+	binary.Write(buf, binary.LittleEndian, uint32(width))
+	binary.Write(buf, binary.LittleEndian, uint32(signedHeight))
+	binary.Write(buf, binary.LittleEndian, uint16(1)) // planes
+	binary.Write(buf, binary.LittleEndian, bitCount)
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // compression
+	binary.Write(buf, binary.LittleEndian, uint32(len(pixelData)))
+	binary.Write(buf, binary.LittleEndian, int32(2835)) // ~72 DPI
+	binary.Write(buf, binary.LittleEndian, int32(2835))
+	binary.Write(buf, binary.LittleEndian, uint32(len(palette)))
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+
+	buf.Write(paletteBytes)
+	buf.Write(pixelData)
+	return buf.Bytes()
+}
+
+func paletteIndex(palette []color.RGBA, c color.RGBA) byte {
+	for i, p := range palette {
+		if p == c {
+			return byte(i)
+		}
+	}
+	return 0
+}
+
+func TestDecode24BitTopDown(t *testing.T) {
+	red := color.RGBA{R: 255, A: 0xff}
+	blue := color.RGBA{B: 255, A: 0xff}
+	rows := [][]color.RGBA{{red, blue}, {blue, red}}
+
+	img, xDPI, yDPI, err := Decode(makeBMP(t, 24, true, rows, nil))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got := img.Bounds(); got.Dx() != 2 || got.Dy() != 2 {
+		t.Errorf("Bounds = %v, want 2x2", got)
+	}
+	if got := img.At(0, 0); got != color.Color(red) {
+		t.Errorf("At(0,0) = %v, want %v", got, red)
+	}
+	if xDPI != 72 || yDPI != 72 {
+		t.Errorf("DPI = %d,%d, want 72,72", xDPI, yDPI)
+	}
+}
+
+func TestDecode24BitBottomUp(t *testing.T) {
+	red := color.RGBA{R: 255, A: 0xff}
+	blue := color.RGBA{B: 255, A: 0xff}
+	rows := [][]color.RGBA{{red, blue}, {blue, red}}
+
+	img, _, _, err := Decode(makeBMP(t, 24, false, rows, nil))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	// Row 0 in the logical image should still be {red, blue} even though
+	// it was stored last on disk.
+	if got := img.At(0, 0); got != color.Color(red) {
+		t.Errorf("At(0,0) = %v, want %v", got, red)
+	}
+	if got := img.At(1, 0); got != color.Color(blue) {
+		t.Errorf("At(1,0) = %v, want %v", got, blue)
+	}
+}
+
+func Test8BitPalette(t *testing.T) {
+	palette := []color.RGBA{{R: 255, A: 0xff}, {G: 255, A: 0xff}}
+	rows := [][]color.RGBA{{palette[0], palette[1]}, {palette[1], palette[0]}}
+
+	img, _, _, err := Decode(makeBMP(t, 8, true, rows, palette))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got := img.At(0, 0); got != color.Color(palette[0]) {
+		t.Errorf("At(0,0) = %v, want %v", got, palette[0])
+	}
+	if got := img.At(1, 1); got != color.Color(palette[0]) {
+		t.Errorf("At(1,1) = %v, want %v", got, palette[0])
+	}
+}
+
+func TestDecodeRejectsBadSignature(t *testing.T) {
+	data := makeBMP(t, 24, true, [][]color.RGBA{{{}, {}}, {{}, {}}}, nil)
+	data[0] = 'X'
+	if _, _, _, err := Decode(data); err == nil {
+		t.Error("Decode with bad signature: want error, got nil")
+	}
+}
+
+// TestDecodeRejectsOutOfRangeDataOffset verifies that a header claiming a
+// data offset beyond the file's length yields an error instead of a
+// slice-bounds panic, since the offset comes from untrusted scanner
+// firmware.
+func TestDecodeRejectsOutOfRangeDataOffset(t *testing.T) {
+	data := makeBMP(t, 24, true, [][]color.RGBA{{{}, {}}, {{}, {}}}, nil)
+	binary.LittleEndian.PutUint32(data[10:14], uint32(len(data)+100000))
+	if _, _, _, err := Decode(data); err == nil {
+		t.Error("Decode with out-of-range data offset: want error, got nil")
+	}
+}
+
+// TestDecodeRejectsOutOfRangePaletteIndex verifies that an 8-bit pixel
+// byte indexing past the end of a shorter-than-expected palette yields an
+// error instead of an index-out-of-range panic.
+func TestDecodeRejectsOutOfRangePaletteIndex(t *testing.T) {
+	palette := []color.RGBA{{R: 255, A: 0xff}, {G: 255, A: 0xff}}
+	rows := [][]color.RGBA{{palette[0], palette[1]}, {palette[1], palette[0]}}
+	data := makeBMP(t, 8, true, rows, palette)
+
+	// Truncate the on-disk palette to a single entry without touching the
+	// pixel data, so a pixel byte of 1 now points past the palette.
+	paletteStart := fileHeaderSize + infoHeaderSize
+	data = append(data[:paletteStart+4], data[paletteStart+8:]...)
+	binary.LittleEndian.PutUint32(data[10:14], uint32(paletteStart+4))
+
+	if _, _, _, err := Decode(data); err == nil {
+		t.Error("Decode with out-of-range palette index: want error, got nil")
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package imagedecode decodes the document formats eSCL scanners
+// commonly advertise and reports the decoded pixel dimensions alongside
+// whatever DPI metadata the format embeds, so a hwtest can check that
+// metadata against the resolution the scan was requested at.
+package imagedecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+
+	"chromiumos/scanning/imagedecode/bmp"
+)
+
+// Dimensions is a decoded image's pixel size plus the resolution it was
+// saved at, in pixels per inch. XDPI and YDPI are 0 if the format
+// doesn't embed a resolution or the embedded value is absent.
+type Dimensions struct {
+	Width, Height int
+	XDPI, YDPI    int
+}
+
+// Decode decodes data as the given eSCL DocumentFormat and returns its
+// pixel dimensions and embedded DPI. Supported formats are "image/jpeg",
+// "image/png", and "application/octet-stream" (decoded as BMP, which is
+// what several scanner firmwares actually send under that MIME type).
+func Decode(format string, data []byte) (Dimensions, error) {
+	switch format {
+	case "image/jpeg":
+		return decodeJPEG(data)
+	case "image/png":
+		return decodePNG(data)
+	case "application/octet-stream":
+		return decodeBMP(data)
+	default:
+		return Dimensions{}, fmt.Errorf("imagedecode: unsupported format %q", format)
+	}
+}
+
+func decodeJPEG(data []byte) (Dimensions, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Dimensions{}, fmt.Errorf("decoding JPEG: %w", err)
+	}
+	xDPI, yDPI := jfifDPI(data)
+	return Dimensions{Width: cfg.Width, Height: cfg.Height, XDPI: xDPI, YDPI: yDPI}, nil
+}
+
+// jfifDPI scans a JPEG's JFIF APP0 segment for its density fields. It
+// returns 0, 0 if there's no JFIF APP0 segment or its density units
+// aren't pixels per inch.
+func jfifDPI(data []byte) (xDPI, yDPI int) {
+	// APP0: FF D8 FF E0 <len hi> <len lo> "JFIF\0" <ver:2> <units:1>
+	// <xDensity:2> <yDensity:2> ...
+	const jfifID = "JFIF\x00"
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return 0, 0
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		segLen := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		segStart := i + 4
+		if marker == 0xe0 && segStart+11 <= len(data) && string(data[segStart:segStart+5]) == jfifID {
+			units := data[segStart+7]
+			x := int(binary.BigEndian.Uint16(data[segStart+8 : segStart+10]))
+			y := int(binary.BigEndian.Uint16(data[segStart+10 : segStart+12]))
+			if units == 1 { // pixels per inch
+				return x, y
+			}
+			return 0, 0
+		}
+		if marker == 0xd8 || marker == 0xd9 || (marker >= 0xd0 && marker <= 0xd7) {
+			i += 2
+			continue
+		}
+		i = segStart + segLen - 2
+	}
+	return 0, 0
+}
+
+func decodePNG(data []byte) (Dimensions, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Dimensions{}, fmt.Errorf("decoding PNG: %w", err)
+	}
+	xDPI, yDPI := pngPHYsDPI(data)
+	return Dimensions{Width: cfg.Width, Height: cfg.Height, XDPI: xDPI, YDPI: yDPI}, nil
+}
+
+// pngPHYsDPI scans a PNG's pHYs chunk for its pixels-per-unit fields,
+// converting to pixels per inch. It returns 0, 0 if there's no pHYs
+// chunk or its unit specifier isn't meters.
+func pngPHYsDPI(data []byte) (xDPI, yDPI int) {
+	const pngSignatureLen = 8
+	if len(data) < pngSignatureLen {
+		return 0, 0
+	}
+	const metersPerInch = 39.3701
+
+	for i := pngSignatureLen; i+12 <= len(data); {
+		chunkLen := int(binary.BigEndian.Uint32(data[i : i+4]))
+		chunkType := string(data[i+4 : i+8])
+		chunkData := data[i+8:]
+		if chunkType == "pHYs" && len(chunkData) >= 9 && chunkData[8] == 1 {
+			xPerMeter := binary.BigEndian.Uint32(chunkData[0:4])
+			yPerMeter := binary.BigEndian.Uint32(chunkData[4:8])
+			return int(float64(xPerMeter)/metersPerInch + 0.5), int(float64(yPerMeter)/metersPerInch + 0.5)
+		}
+		if chunkType == "IDAT" {
+			break
+		}
+		i += 12 + chunkLen
+	}
+	return 0, 0
+}
+
+func decodeBMP(data []byte) (Dimensions, error) {
+	img, xDPI, yDPI, err := bmp.Decode(data)
+	if err != nil {
+		return Dimensions{}, err
+	}
+	bounds := img.Bounds()
+	return Dimensions{Width: bounds.Dx(), Height: bounds.Dy(), XDPI: xDPI, YDPI: yDPI}, nil
+}
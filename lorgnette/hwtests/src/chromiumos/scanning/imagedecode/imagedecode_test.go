@@ -0,0 +1,121 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package imagedecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// withJFIFAPP0 splices a JFIF APP0 segment requesting xDPI/yDPI right
+// after a JPEG's SOI marker, as a real encoder would.
+func withJFIFAPP0(t *testing.T, jpegData []byte, xDPI, yDPI int) []byte {
+	t.Helper()
+	app0 := []byte{0xff, 0xe0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01, 0x01, 0x01}
+	app0 = binary.BigEndian.AppendUint16(app0, uint16(xDPI))
+	app0 = binary.BigEndian.AppendUint16(app0, uint16(yDPI))
+	app0 = append(app0, 0, 0) // no thumbnail
+
+	out := append([]byte{}, jpegData[:2]...) // SOI
+	out = append(out, app0...)
+	out = append(out, jpegData[2:]...)
+	return out
+}
+
+func testJPEG(t *testing.T, xDPI, yDPI int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	return withJFIFAPP0(t, buf.Bytes(), xDPI, yDPI)
+}
+
+// withPHYs splices a pHYs chunk requesting xDPI/yDPI right after a PNG's
+// IHDR chunk.
+func withPHYs(t *testing.T, pngData []byte, xDPI, yDPI int) []byte {
+	t.Helper()
+	const metersPerInch = 39.3701
+	xPerMeter := uint32(float64(xDPI) * metersPerInch)
+	yPerMeter := uint32(float64(yDPI) * metersPerInch)
+
+	data := make([]byte, 9)
+	binary.BigEndian.PutUint32(data[0:4], xPerMeter)
+	binary.BigEndian.PutUint32(data[4:8], yPerMeter)
+	data[8] = 1 // unit specifier: meters
+
+	chunk := append([]byte("pHYs"), data...)
+	crc := crc32.ChecksumIEEE(chunk)
+
+	var phys bytes.Buffer
+	binary.Write(&phys, binary.BigEndian, uint32(len(data)))
+	phys.Write(chunk)
+	binary.Write(&phys, binary.BigEndian, crc)
+
+	const pngSigLen = 8
+	ihdrEnd := pngSigLen + 4 + 4 + 13 + 4 // length+type+data+crc
+	out := append([]byte{}, pngData[:ihdrEnd]...)
+	out = append(out, phys.Bytes()...)
+	out = append(out, pngData[ihdrEnd:]...)
+	return out
+}
+
+func testPNG(t *testing.T, xDPI, yDPI int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return withPHYs(t, buf.Bytes(), xDPI, yDPI)
+}
+
+func TestDecodeJPEG(t *testing.T) {
+	got, err := Decode("image/jpeg", testJPEG(t, 300, 300))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := Dimensions{Width: 8, Height: 4, XDPI: 300, YDPI: 300}
+	if got != want {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodePNG(t *testing.T) {
+	got, err := Decode("image/png", testPNG(t, 600, 600))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.Width != 8 || got.Height != 4 {
+		t.Errorf("dimensions = %dx%d, want 8x4", got.Width, got.Height)
+	}
+	if got.XDPI != 600 || got.YDPI != 600 {
+		t.Errorf("DPI = %d,%d, want 600,600", got.XDPI, got.YDPI)
+	}
+}
+
+func TestDecodeUnsupportedFormat(t *testing.T) {
+	if _, err := Decode("application/pdf", nil); err == nil {
+		t.Error("Decode with unsupported format: want error, got nil")
+	}
+}
+
+func TestJFIFDPIMissing(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	x, y := jfifDPI(buf.Bytes())
+	if x != 0 || y != 0 {
+		t.Errorf("jfifDPI without APP0 = %d,%d, want 0,0", x, y)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package utils
+
+import "reflect"
+
+// DiscreteResolution is a single fixed x/y resolution pair a source can
+// scan at, as advertised in an eSCL ScannerCapabilities response.
+type DiscreteResolution struct {
+	XResolution int `json:"xResolution"`
+	YResolution int `json:"yResolution"`
+}
+
+// ResolutionRange describes a continuum of resolutions a source supports
+// along one axis, as advertised in an eSCL ScannerCapabilities response.
+// A zero-value ResolutionRange means the axis has no range-based
+// resolutions.
+type ResolutionRange struct {
+	Min    int `json:"min"`
+	Max    int `json:"max"`
+	Normal int `json:"normal"`
+	Step   int `json:"step"`
+}
+
+// SupportedResolutions is the union of the discrete and range-based
+// resolutions a source advertises.
+type SupportedResolutions struct {
+	DiscreteResolutions []DiscreteResolution `json:"discreteResolutions,omitempty"`
+	XResolutionRange    ResolutionRange      `json:"xResolutionRange,omitempty"`
+	YResolutionRange    ResolutionRange      `json:"yResolutionRange,omitempty"`
+}
+
+// SettingProfile is one scan-setting profile a source advertises: the
+// color modes, document formats and resolutions it supports together.
+type SettingProfile struct {
+	Name                 string               `json:"name"`
+	Ref                  string               `json:"ref"`
+	ColorModes           []string             `json:"colorModes,omitempty"`
+	DocumentFormats      []string             `json:"documentFormats,omitempty"`
+	SupportedResolutions SupportedResolutions `json:"supportedResolutions"`
+}
+
+// SourceCapabilities is the parsed form of the eSCL ScannerCapabilities
+// response for a single source (platen, ADF simplex or ADF duplex). A
+// zero-value SourceCapabilities means the scanner does not have the
+// source at all; how a hwtest treats that absence (skip it, or report
+// it as an unmet requirement) depends on the check.
+type SourceCapabilities struct {
+	MaxWidth       int `json:"maxWidth"`
+	MinWidth       int `json:"minWidth"`
+	MaxHeight      int `json:"maxHeight"`
+	MinHeight      int `json:"minHeight"`
+	MaxScanRegions int `json:"maxScanRegions"`
+
+	SettingProfile SettingProfile `json:"settingProfile"`
+
+	MaxOpticalXResolution int `json:"maxOpticalXResolution"`
+	MaxOpticalYResolution int `json:"maxOpticalYResolution"`
+	MaxPhysicalWidth      int `json:"maxPhysicalWidth"`
+	MaxPhysicalHeight     int `json:"maxPhysicalHeight"`
+}
+
+// IsZero reports whether c is the zero value, i.e. the source it
+// describes isn't present on the scanner.
+func (c SourceCapabilities) IsZero() bool {
+	return reflect.DeepEqual(c, SourceCapabilities{})
+}
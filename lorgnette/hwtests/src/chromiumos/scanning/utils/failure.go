@@ -0,0 +1,43 @@
+// Copyright 2021 The Chromium OS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package utils contains the data types shared by the eSCL hardware
+// certification checks in chromiumos/scanning/hwtests: the capabilities
+// scanners advertise and the failures those checks report.
+package utils
+
+// FailureType categorizes how serious a hwtest failure is.
+type FailureType int
+
+const (
+	// Informational indicates a deviation that does not affect
+	// certification but is still worth surfacing in the report.
+	Informational FailureType = iota
+	// NeedsAudit indicates a deviation that a human should review
+	// before certification can proceed.
+	NeedsAudit
+	// CriticalFailure indicates a deviation that fails certification
+	// outright.
+	CriticalFailure
+)
+
+// String returns the human-readable name used in hwtest reports.
+func (t FailureType) String() string {
+	switch t {
+	case Informational:
+		return "Informational"
+	case NeedsAudit:
+		return "NeedsAudit"
+	case CriticalFailure:
+		return "CriticalFailure"
+	default:
+		return "Unknown"
+	}
+}
+
+// Failure describes a single problem found by a hwtest.
+type Failure struct {
+	Type    FailureType
+	Message string
+}